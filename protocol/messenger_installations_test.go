@@ -239,3 +239,15 @@ func (s *MessengerInstallationSuite) TestSyncInstallation() {
 
 	s.Require().True(actualContact.IsAdded())
 }
+
+// TestSyncInstallationPushNotificationServer is intentionally unimplemented:
+// syncing push-notification server enrollment across paired installations
+// (chunk0-4) needs SyncDevices to include it in the payload it assembles
+// and a dispatch handler registered for the resulting message, both of
+// which live in messenger.go/messenger_handler.go -- neither is part of
+// this checkout. Skipping rather than deleting so the gap stays visible
+// here instead of only in history: fill this in alongside that wiring when
+// messenger.go is available to edit.
+func (s *MessengerInstallationSuite) TestSyncInstallationPushNotificationServer() {
+	s.T().Skip("requires SyncDevices push-notification-server wiring and dispatch registration in messenger.go, not part of this checkout (chunk0-4)")
+}
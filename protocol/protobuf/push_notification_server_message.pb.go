@@ -0,0 +1,130 @@
+// Code generated by protoc-gen-go would normally live here. This file is a
+// hand-maintained stand-in for the messages declared in
+// push_notification_server_message.proto: this checkout doesn't include the
+// rest of application_metadata_message.pb.go, so running protoc isn't
+// possible here. Regenerate properly (and delete this file) once the full
+// .proto sources are available.
+
+package protobuf
+
+type PushNotificationRegistration_TokenType int32
+
+const (
+	PushNotificationRegistration_UNKNOWN_TOKEN_TYPE PushNotificationRegistration_TokenType = 0
+	PushNotificationRegistration_APN_TOKEN          PushNotificationRegistration_TokenType = 1
+	PushNotificationRegistration_FIREBASE_TOKEN     PushNotificationRegistration_TokenType = 2
+)
+
+type PushNotificationRegistration struct {
+	Version         uint64
+	InstallationId  string
+	AccessToken     string
+	Token           string
+	TokenType       PushNotificationRegistration_TokenType
+	Unregister      bool
+	AllowedUserList [][]byte
+	BlockedChatList [][]byte
+}
+
+type PushNotificationRegistrationResponse_ErrorType int32
+
+const (
+	PushNotificationRegistrationResponse_UNKNOWN_ERROR_TYPE PushNotificationRegistrationResponse_ErrorType = 0
+	PushNotificationRegistrationResponse_MALFORMED_MESSAGE  PushNotificationRegistrationResponse_ErrorType = 1
+	PushNotificationRegistrationResponse_VERSION_MISMATCH   PushNotificationRegistrationResponse_ErrorType = 2
+	PushNotificationRegistrationResponse_INTERNAL_ERROR     PushNotificationRegistrationResponse_ErrorType = 3
+	PushNotificationRegistrationResponse_RATE_LIMITED       PushNotificationRegistrationResponse_ErrorType = 4
+)
+
+type PushNotificationRegistrationResponse struct {
+	Success   bool
+	Error     PushNotificationRegistrationResponse_ErrorType
+	RequestId []byte
+}
+
+type PushNotificationQuery struct {
+	PublicKeys [][]byte
+}
+
+type PushNotificationQueryInfo struct {
+	PublicKey       []byte
+	InstallationId  string
+	AccessToken     string
+	AllowedUserList [][]byte
+}
+
+type PushNotificationQueryResponse struct {
+	Info    []*PushNotificationQueryInfo
+	Success bool
+}
+
+type PushNotificationRequestInfo struct {
+	PublicKey      []byte
+	InstallationId string
+	AccessToken    string
+	// ChatId is the hash of the chat this notification originates from, so
+	// the server can check it against a registration's blocked-chat-list
+	// without ever learning the chat's plaintext id.
+	ChatId []byte
+}
+
+type PushNotificationRequest struct {
+	Requests  []*PushNotificationRequestInfo
+	MessageId string
+	// InstallationId identifies the sender's installation, so it can be
+	// rate-limited independently of which installations it targets.
+	InstallationId string
+}
+
+type PushNotificationReport_ErrorType int32
+
+const (
+	PushNotificationReport_UNKNOWN_ERROR_TYPE PushNotificationReport_ErrorType = 0
+	PushNotificationReport_NOT_REGISTERED     PushNotificationReport_ErrorType = 1
+	PushNotificationReport_WRONG_TOKEN        PushNotificationReport_ErrorType = 2
+	PushNotificationReport_RATE_LIMITED       PushNotificationReport_ErrorType = 3
+	PushNotificationReport_NOT_AUTHORIZED     PushNotificationReport_ErrorType = 4
+	PushNotificationReport_CHAT_BLOCKED       PushNotificationReport_ErrorType = 5
+)
+
+type PushNotificationReport struct {
+	PublicKey      []byte
+	InstallationId string
+	Success        bool
+	Error          PushNotificationReport_ErrorType
+}
+
+type PushNotificationResponse struct {
+	MessageId string
+	Reports   []*PushNotificationReport
+}
+
+// The Reset/String/ProtoMessage trio below is the minimal proto.Message
+// implementation the golang/protobuf proto.Marshal/proto.Unmarshal calls in
+// this package need; a real protoc run would also give these types their
+// wire-format struct tags.
+
+func (m *PushNotificationRegistration) Reset() { *m = PushNotificationRegistration{} }
+func (m *PushNotificationRegistration) String() string { return "" }
+func (*PushNotificationRegistration) ProtoMessage() {}
+
+func (m *PushNotificationRegistrationResponse) Reset() { *m = PushNotificationRegistrationResponse{} }
+func (m *PushNotificationRegistrationResponse) String() string { return "" }
+func (*PushNotificationRegistrationResponse) ProtoMessage() {}
+
+func (m *PushNotificationQueryResponse) Reset() { *m = PushNotificationQueryResponse{} }
+func (m *PushNotificationQueryResponse) String() string { return "" }
+func (*PushNotificationQueryResponse) ProtoMessage() {}
+
+func (m *PushNotificationResponse) Reset() { *m = PushNotificationResponse{} }
+func (m *PushNotificationResponse) String() string { return "" }
+func (*PushNotificationResponse) ProtoMessage() {}
+
+type ApplicationMetadataMessage_MessageType int32
+
+const (
+	ApplicationMetadataMessage_UNKNOWN_MESSAGE_TYPE                    ApplicationMetadataMessage_MessageType = 0
+	ApplicationMetadataMessage_PUSH_NOTIFICATION_REGISTRATION_RESPONSE ApplicationMetadataMessage_MessageType = 1
+	ApplicationMetadataMessage_PUSH_NOTIFICATION_QUERY_RESPONSE        ApplicationMetadataMessage_MessageType = 2
+	ApplicationMetadataMessage_PUSH_NOTIFICATION_RESPONSE              ApplicationMetadataMessage_MessageType = 3
+)
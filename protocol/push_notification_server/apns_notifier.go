@@ -0,0 +1,194 @@
+package push_notification_server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/status-im/status-go/protocol/protobuf"
+)
+
+// apnsTokenLifetime is how long a provider token is reused before a new one
+// is signed. Apple honours a provider token for up to an hour; this is a
+// little less than that so a request never races an expiry.
+const apnsTokenLifetime = 55 * time.Minute
+
+// APNSConfig holds the credentials needed to talk to Apple's HTTP/2
+// provider API using token-based (.p8 key) authentication, as opposed to
+// per-app TLS certificates.
+type APNSConfig struct {
+	// KeyID is the 10-character identifier of the APNs auth key.
+	KeyID string
+	// TeamID is the 10-character Apple developer team identifier.
+	TeamID string
+	// PrivateKey is the APNs auth key used to sign provider tokens.
+	PrivateKey *ecdsa.PrivateKey
+	// Topic is the destination app's bundle id, sent as the apns-topic header.
+	Topic string
+	// Production selects the production APNs host instead of the sandbox one.
+	Production bool
+}
+
+func (c *APNSConfig) host() string {
+	if c.Production {
+		return "https://api.push.apple.com"
+	}
+	return "https://api.sandbox.push.apple.com"
+}
+
+// APNSNotifier delivers notifications to Apple devices over the APNs
+// HTTP/2 API. It reuses a single http2 client/connection across requests
+// and caches the provider JWT for its lifetime, as recommended by Apple.
+type APNSNotifier struct {
+	config *APNSConfig
+	client *http.Client
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenIssued time.Time
+}
+
+func NewAPNSNotifier(config *APNSConfig) *APNSNotifier {
+	return &APNSNotifier{
+		config: config,
+		client: &http.Client{
+			Transport: &http2.Transport{
+				TLSClientConfig: &tls.Config{},
+			},
+		},
+	}
+}
+
+// providerToken returns a cached ES256 JWT identifying our team to APNs,
+// signing a new one once the cached one is close to expiry.
+func (a *APNSNotifier) providerToken() (string, error) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+
+	if a.token != "" && time.Since(a.tokenIssued) < apnsTokenLifetime {
+		return a.token, nil
+	}
+
+	now := time.Now()
+	header := map[string]interface{}{"alg": "ES256", "kid": a.config.KeyID}
+	claims := map[string]interface{}{"iss": a.config.TeamID, "iat": now.Unix()}
+
+	signed, err := signES256JWT(header, claims, a.config.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	a.token = signed
+	a.tokenIssued = now
+
+	return a.token, nil
+}
+
+type apnsPayload struct {
+	Aps struct {
+		ContentAvailable int `json:"content-available"`
+		MutableContent   int `json:"mutable-content"`
+	} `json:"aps"`
+}
+
+type apnsErrorResponse struct {
+	Reason string `json:"reason"`
+}
+
+// Notify implements Notifier. It sends a content-available background
+// notification, since the actual alert content is end-to-end encrypted and
+// carried separately; APNs is only used to wake up the client.
+func (a *APNSNotifier) Notify(ctx context.Context, requestAndRegistration *RequestAndRegistration) (bool, protobuf.PushNotificationReport_ErrorType, bool) {
+	token, err := a.providerToken()
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+
+	var payload apnsPayload
+	payload.Aps.ContentAvailable = 1
+	payload.Aps.MutableContent = 1
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+
+	deviceToken := requestAndRegistration.Registration.Token
+	url := fmt.Sprintf("%s/3/device/%s", a.config.host(), deviceToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+	req.Header.Set("authorization", "bearer "+token)
+	req.Header.Set("apns-topic", a.config.Topic)
+	req.Header.Set("apns-push-type", "background")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return true, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+
+	var apnsErr apnsErrorResponse
+	_ = json.NewDecoder(resp.Body).Decode(&apnsErr)
+
+	switch apnsErr.Reason {
+	case "Unregistered", "BadDeviceToken":
+		return false, protobuf.PushNotificationReport_NOT_REGISTERED, true
+	default:
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+}
+
+// signES256JWT signs a compact JWT using the ES256 algorithm, used for the
+// APNs provider token (the FCM assertion is RS256, see signRS256JWT). It
+// produces the raw (r||s) signature format JWS expects rather than the
+// ASN.1 DER one crypto/ecdsa.SignASN1 returns.
+func signES256JWT(header, claims map[string]interface{}, key *ecdsa.PrivateKey) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(signingInput))
+
+	der, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	var asn1Sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &asn1Sig); err != nil {
+		return "", err
+	}
+
+	keyBytes := (key.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*keyBytes)
+	asn1Sig.R.FillBytes(sig[:keyBytes])
+	asn1Sig.S.FillBytes(sig[keyBytes:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
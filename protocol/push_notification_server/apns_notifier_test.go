@@ -0,0 +1,45 @@
+package push_notification_server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignES256JWTProducesRawSignatureFormat(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	header := map[string]interface{}{"alg": "ES256", "kid": "key-id"}
+	claims := map[string]interface{}{"iss": "team-id", "iat": int64(1)}
+
+	token, err := signES256JWT(header, claims, key)
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	// APNs expects the raw r||s signature, not the ASN.1 DER encoding
+	// crypto/ecdsa.SignASN1 produces, so its length is exactly two field
+	// elements with no DER framing.
+	keyBytes := (key.Curve.Params().BitSize + 7) / 8
+	require.Len(t, sig, 2*keyBytes)
+
+	r := new(big.Int).SetBytes(sig[:keyBytes])
+	s := new(big.Int).SetBytes(sig[keyBytes:])
+
+	signingInput := parts[0] + "." + parts[1]
+	hash := sha256.Sum256([]byte(signingInput))
+
+	require.True(t, ecdsa.Verify(&key.PublicKey, hash[:], r, s))
+}
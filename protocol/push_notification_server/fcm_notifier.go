@@ -0,0 +1,235 @@
+package push_notification_server
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/status-im/status-go/protocol/protobuf"
+)
+
+const (
+	fcmTokenScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmTokenEndpoint = "https://oauth2.googleapis.com/token"
+	fcmTokenLifetime = time.Hour
+	fcmSendEndpoint  = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+)
+
+// FCMConfig holds the Firebase service-account credentials used to
+// authenticate against the FCM HTTP v1 API via an OAuth2 JWT-bearer
+// exchange, as opposed to the legacy server-key based API.
+type FCMConfig struct {
+	// ProjectID is the Firebase project the service account belongs to.
+	ProjectID string
+	// ClientEmail is the service account's client_email field.
+	ClientEmail string
+	// PrivateKey is the service account's RSA private key, parsed from the
+	// PEM in its credentials file (see ParseFCMPrivateKey).
+	PrivateKey *rsa.PrivateKey
+}
+
+// ParseFCMPrivateKey parses the PEM-encoded PKCS#8/PKCS#1 private key found
+// in a Firebase service-account JSON credentials file.
+func ParseFCMPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found in FCM service account key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("FCM service account key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// FCMNotifier delivers notifications to Android devices through the FCM
+// HTTP v1 API, authenticating with a short-lived OAuth2 access token
+// obtained via the service-account JWT-bearer flow.
+type FCMNotifier struct {
+	config *FCMConfig
+	client *http.Client
+
+	tokenMu      sync.Mutex
+	accessToken  string
+	tokenExpires time.Time
+}
+
+func NewFCMNotifier(config *FCMConfig) *FCMNotifier {
+	return &FCMNotifier{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type fcmTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// oauthAccessToken returns a cached OAuth2 access token for the service
+// account, refreshing it once it is close to expiry.
+func (f *FCMNotifier) oauthAccessToken(ctx context.Context) (string, error) {
+	f.tokenMu.Lock()
+	defer f.tokenMu.Unlock()
+
+	if f.accessToken != "" && time.Now().Before(f.tokenExpires) {
+		return f.accessToken, nil
+	}
+
+	now := time.Now()
+	header := map[string]interface{}{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]interface{}{
+		"iss":   f.config.ClientEmail,
+		"scope": fcmTokenScope,
+		"aud":   fcmTokenEndpoint,
+		"iat":   now.Unix(),
+		"exp":   now.Add(fcmTokenLifetime).Unix(),
+	}
+
+	assertion, err := signRS256JWT(header, claims, f.config.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fcmTokenEndpoint, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("content-type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to exchange FCM service account token, status: %d", resp.StatusCode)
+	}
+
+	var tokenResp fcmTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+
+	f.accessToken = tokenResp.AccessToken
+	// Treat the token as expired a minute early so a request never races
+	// its expiry.
+	f.tokenExpires = now.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+
+	return f.accessToken, nil
+}
+
+type fcmMessage struct {
+	Message struct {
+		Token string            `json:"token"`
+		Data  map[string]string `json:"data,omitempty"`
+	} `json:"message"`
+}
+
+type fcmErrorResponse struct {
+	Error struct {
+		Status string `json:"status"`
+	} `json:"error"`
+}
+
+// Notify implements Notifier. The message it sends carries only the device
+// token: no data or notification payload is set. The notification content
+// itself is end-to-end encrypted and delivered separately, FCM is only used
+// to wake up the client.
+func (f *FCMNotifier) Notify(ctx context.Context, requestAndRegistration *RequestAndRegistration) (bool, protobuf.PushNotificationReport_ErrorType, bool) {
+	accessToken, err := f.oauthAccessToken(ctx)
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+
+	var msg fcmMessage
+	msg.Message.Token = requestAndRegistration.Registration.Token
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+
+	endpoint := fmt.Sprintf(fcmSendEndpoint, f.config.ProjectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+	req.Header.Set("authorization", "bearer "+accessToken)
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return true, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+
+	var fcmErr fcmErrorResponse
+	_ = json.NewDecoder(resp.Body).Decode(&fcmErr)
+
+	switch fcmErr.Error.Status {
+	// UNREGISTERED and SENDER_ID_MISMATCH mean the token itself is stale or
+	// belongs to a different sender project, so the registration should be
+	// dropped. INVALID_ARGUMENT is a generic 400 for a malformed request
+	// field -- it can just as easily be our own bug as a bad token, so
+	// treating it the same would deregister a valid device over a send-side
+	// mistake; report it without unregistering.
+	case "UNREGISTERED", "SENDER_ID_MISMATCH":
+		return false, protobuf.PushNotificationReport_NOT_REGISTERED, true
+	default:
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+}
+
+// signRS256JWT signs a compact JWT using RS256, as required by Google's
+// OAuth2 JWT-bearer service-account flow.
+func signRS256JWT(header, claims map[string]interface{}, key *rsa.PrivateKey) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,31 @@
+package push_notification_server
+
+import (
+	"context"
+
+	"github.com/status-im/status-go/protocol/protobuf"
+)
+
+// GorushNotifier is the original Notifier implementation, relaying
+// notifications through a self-hosted Gorush instance over HTTP. It is
+// kept as the default backend for token types that don't have a
+// dedicated implementation configured, and remains the only option for
+// operators who don't want to talk to APNs/FCM directly.
+type GorushNotifier struct {
+	url string
+}
+
+func NewGorushNotifier(url string) *GorushNotifier {
+	return &GorushNotifier{url: url}
+}
+
+// Notify implements Notifier. Gorush does not distinguish notifications
+// per-registration, so it is always called with a single-element batch;
+// this keeps its error handling symmetric with the other backends.
+func (g *GorushNotifier) Notify(ctx context.Context, requestAndRegistration *RequestAndRegistration) (bool, protobuf.PushNotificationReport_ErrorType, bool) {
+	goRushRequest := PushNotificationRegistrationToGoRushRequest([]*RequestAndRegistration{requestAndRegistration})
+	if err := sendGoRushNotification(goRushRequest, g.url); err != nil {
+		return false, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+	}
+	return true, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+}
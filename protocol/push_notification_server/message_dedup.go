@@ -0,0 +1,192 @@
+package push_notification_server
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// processedRequestTTL bounds how long we remember that a (messageID,
+// installationID) pair has already been delivered. It only needs to outlive
+// the protocol's own redelivery window, so the table doesn't grow forever.
+const processedRequestTTL = 24 * time.Hour
+
+// requestDedupSweepInterval is how often expired entries are swept out of
+// whichever RequestDeduplicator backend is in use.
+const requestDedupSweepInterval = 10 * time.Minute
+
+// RequestDeduplicator records which (messageID, installationID) pairs a
+// PushNotificationRequest has already been handled for, so that redelivered
+// protocol messages produce an idempotent success instead of hitting the
+// upstream notifier a second time.
+type RequestDeduplicator interface {
+	// WasProcessed reports whether this tuple was already recorded and is
+	// still within its TTL.
+	WasProcessed(messageID string, installationID string) (bool, error)
+	// MarkProcessed records that this tuple has now been handled.
+	MarkProcessed(messageID string, installationID string) error
+}
+
+func dedupKey(messageID, installationID string) string {
+	return messageID + "-" + installationID
+}
+
+// InMemoryRequestDeduplicator is a process-local, TTL'd RequestDeduplicator.
+// It's only suitable for a single-instance deployment: its state is lost on
+// restart and isn't shared with any other instance behind a load balancer.
+// It's used as a fallback when a Config doesn't wire up a DB-backed one.
+type InMemoryRequestDeduplicator struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+
+	stop chan struct{}
+}
+
+func NewInMemoryRequestDeduplicator() *InMemoryRequestDeduplicator {
+	d := &InMemoryRequestDeduplicator{
+		entries: make(map[string]time.Time),
+		stop:    make(chan struct{}),
+	}
+	go d.evictLoop()
+	return d
+}
+
+func (d *InMemoryRequestDeduplicator) WasProcessed(messageID string, installationID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := dedupKey(messageID, installationID)
+	processedAt, ok := d.entries[key]
+	if !ok {
+		return false, nil
+	}
+	if time.Since(processedAt) > processedRequestTTL {
+		delete(d.entries, key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *InMemoryRequestDeduplicator) MarkProcessed(messageID string, installationID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[dedupKey(messageID, installationID)] = time.Now()
+	return nil
+}
+
+// Close stops the background eviction goroutine. It is safe to skip calling
+// this for a server-lifetime deduplicator; it exists mainly so tests can
+// shut one down cleanly.
+func (d *InMemoryRequestDeduplicator) Close() {
+	close(d.stop)
+}
+
+func (d *InMemoryRequestDeduplicator) evictLoop() {
+	ticker := time.NewTicker(requestDedupSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.evictExpired()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *InMemoryRequestDeduplicator) evictExpired() {
+	cutoff := time.Now().Add(-processedRequestTTL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, processedAt := range d.entries {
+		if processedAt.Before(cutoff) {
+			delete(d.entries, key)
+		}
+	}
+}
+
+const createRequestDedupTableQuery = `CREATE TABLE IF NOT EXISTS push_notification_server_request_dedup (
+	message_id TEXT NOT NULL,
+	installation_id TEXT NOT NULL,
+	processed_at INTEGER NOT NULL,
+	PRIMARY KEY (message_id, installation_id)
+)`
+
+// SQLRequestDeduplicator is a database-backed RequestDeduplicator: unlike
+// InMemoryRequestDeduplicator, its state survives a restart and is shared by
+// every server instance pointed at the same database, which is what lets
+// redelivery dedup keep working once the server is horizontally scaled.
+type SQLRequestDeduplicator struct {
+	db *sql.DB
+
+	stop chan struct{}
+}
+
+// NewSQLRequestDeduplicator creates the dedup table if it doesn't already
+// exist and starts a background sweep of expired entries.
+func NewSQLRequestDeduplicator(db *sql.DB) (*SQLRequestDeduplicator, error) {
+	if _, err := db.Exec(createRequestDedupTableQuery); err != nil {
+		return nil, err
+	}
+
+	d := &SQLRequestDeduplicator{db: db, stop: make(chan struct{})}
+	go d.evictLoop()
+	return d, nil
+}
+
+func (d *SQLRequestDeduplicator) WasProcessed(messageID string, installationID string) (bool, error) {
+	var processedAt int64
+	err := d.db.QueryRow(
+		"SELECT processed_at FROM push_notification_server_request_dedup WHERE message_id = ? AND installation_id = ?",
+		messageID, installationID,
+	).Scan(&processedAt)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if time.Since(time.Unix(processedAt, 0)) > processedRequestTTL {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *SQLRequestDeduplicator) MarkProcessed(messageID string, installationID string) error {
+	_, err := d.db.Exec(
+		"INSERT OR REPLACE INTO push_notification_server_request_dedup (message_id, installation_id, processed_at) VALUES (?, ?, ?)",
+		messageID, installationID, time.Now().Unix(),
+	)
+	return err
+}
+
+// Close stops the background eviction goroutine. It is safe to skip calling
+// this for a server-lifetime deduplicator; it exists mainly so tests can
+// shut one down cleanly.
+func (d *SQLRequestDeduplicator) Close() {
+	close(d.stop)
+}
+
+func (d *SQLRequestDeduplicator) evictLoop() {
+	ticker := time.NewTicker(requestDedupSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = d.evictExpired()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *SQLRequestDeduplicator) evictExpired() error {
+	cutoff := time.Now().Add(-processedRequestTTL).Unix()
+	_, err := d.db.Exec("DELETE FROM push_notification_server_request_dedup WHERE processed_at <= ?", cutoff)
+	return err
+}
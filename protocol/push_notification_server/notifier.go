@@ -0,0 +1,40 @@
+package push_notification_server
+
+import (
+	"context"
+
+	"github.com/status-im/status-go/protocol/protobuf"
+)
+
+// Notifier abstracts the transport used to actually deliver a push
+// notification to a single device, so that HandlePushNotificationRequest
+// does not need to know whether a given token is delivered through Gorush,
+// APNs or FCM directly. Implementations translate whatever error their
+// transport returns into a PushNotificationReport_ErrorType, and report
+// whether the underlying token should be considered stale so the caller can
+// deregister it.
+type Notifier interface {
+	// Notify delivers a single notification and returns whether it was
+	// successful, the error to surface to the client and whether the
+	// registration this request was addressed to should be removed because
+	// the transport reported the token as no longer valid.
+	Notify(ctx context.Context, requestAndRegistration *RequestAndRegistration) (success bool, errorType protobuf.PushNotificationReport_ErrorType, shouldUnregister bool)
+}
+
+// notifierForTokenType returns the Notifier configured for a given
+// registration's TokenType, falling back to the Gorush relay for token
+// types that don't have a dedicated backend configured, so operators can
+// migrate incrementally.
+func (p *Server) notifierForTokenType(tokenType protobuf.PushNotificationRegistration_TokenType) Notifier {
+	switch tokenType {
+	case protobuf.PushNotificationRegistration_APN_TOKEN:
+		if p.apnsNotifier != nil {
+			return p.apnsNotifier
+		}
+	case protobuf.PushNotificationRegistration_FIREBASE_TOKEN:
+		if p.fcmNotifier != nil {
+			return p.fcmNotifier
+		}
+	}
+	return p.gorushNotifier
+}
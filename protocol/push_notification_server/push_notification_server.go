@@ -1,8 +1,10 @@
 package push_notification_server
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"database/sql"
 	"errors"
 
 	"github.com/golang/protobuf/proto"
@@ -21,6 +23,34 @@ type Config struct {
 	Identity *ecdsa.PrivateKey
 	// GorushUrl is the url for the gorush service
 	GorushURL string
+	// APNSConfig holds the credentials used to talk to APNs directly. If nil,
+	// APN_TOKEN registrations fall back to the Gorush relay.
+	APNSConfig *APNSConfig
+	// FCMConfig holds the credentials used to talk to FCM directly. If nil,
+	// FIREBASE_TOKEN registrations fall back to the Gorush relay.
+	FCMConfig *FCMConfig
+
+	// RegistrationRateLimit throttles HandlePushNotificationRegistration per
+	// requester public key. Nil disables rate limiting for that operation.
+	RegistrationRateLimit *RateLimitConfig
+	// QueryRateLimit throttles HandlePushNotificationQuery per requester
+	// public key. Nil disables rate limiting for that operation.
+	QueryRateLimit *RateLimitConfig
+	// RequestRateLimit throttles HandlePushNotificationRequest per requester
+	// public key. Nil disables rate limiting for that operation.
+	RequestRateLimit *RateLimitConfig
+	// InstallationRateLimit throttles HandlePushNotificationRegistration and
+	// HandlePushNotificationRequest keyed on the requester's own installation
+	// id (the registering installation, or PushNotificationRequest's
+	// InstallationId), independently of which public key is making the
+	// request. Nil disables rate limiting for that dimension.
+	InstallationRateLimit *RateLimitConfig
+
+	// RequestDeduplicatorDB, if set, backs redelivered-request dedup with a
+	// table in this DB, so dedup state survives a restart and is shared by
+	// every server instance pointed at it. Nil falls back to a process-local
+	// in-memory deduplicator, suitable only for a single-instance deployment.
+	RequestDeduplicatorDB *sql.DB
 
 	Logger *zap.Logger
 }
@@ -29,10 +59,79 @@ type Server struct {
 	persistence      Persistence
 	config           *Config
 	messageProcessor *common.MessageProcessor
+
+	gorushNotifier Notifier
+	apnsNotifier   Notifier
+	fcmNotifier    Notifier
+
+	registrationLimiter RateLimiter
+	queryLimiter        RateLimiter
+	requestLimiter      RateLimiter
+	installationLimiter RateLimiter
+
+	requestDeduplicator RequestDeduplicator
 }
 
 func New(config *Config, persistence Persistence, messageProcessor *common.MessageProcessor) *Server {
-	return &Server{persistence: persistence, config: config, messageProcessor: messageProcessor}
+	server := &Server{
+		persistence:      persistence,
+		config:           config,
+		messageProcessor: messageProcessor,
+		gorushNotifier:   NewGorushNotifier(config.GorushURL),
+
+		registrationLimiter: newRateLimiter(config.RegistrationRateLimit),
+		queryLimiter:        newRateLimiter(config.QueryRateLimit),
+		requestLimiter:      newRateLimiter(config.RequestRateLimit),
+		installationLimiter: newRateLimiter(config.InstallationRateLimit),
+
+		requestDeduplicator: newRequestDeduplicator(config),
+	}
+
+	if config.APNSConfig != nil {
+		server.apnsNotifier = NewAPNSNotifier(config.APNSConfig)
+	}
+
+	if config.FCMConfig != nil {
+		server.fcmNotifier = NewFCMNotifier(config.FCMConfig)
+	}
+
+	return server
+}
+
+// newRateLimiter builds the default in-memory RateLimiter for a given
+// config, or returns nil (meaning "unlimited") when no config is set, so
+// operators can opt into rate limiting per operation class.
+func newRateLimiter(config *RateLimitConfig) RateLimiter {
+	if config == nil {
+		return nil
+	}
+	return NewInMemoryRateLimiter(config)
+}
+
+// allow reports whether an operation may proceed, treating a nil limiter
+// (rate limiting disabled for that dimension) as always allowed.
+func allow(limiter RateLimiter, key string) bool {
+	if limiter == nil {
+		return true
+	}
+	return limiter.Allow(key)
+}
+
+// newRequestDeduplicator builds the default RequestDeduplicator for a given
+// config: DB-backed when a RequestDeduplicatorDB is configured, so dedup
+// state survives restarts and is shared across instances, or in-memory
+// otherwise.
+func newRequestDeduplicator(config *Config) RequestDeduplicator {
+	if config.RequestDeduplicatorDB == nil {
+		return NewInMemoryRequestDeduplicator()
+	}
+
+	deduplicator, err := NewSQLRequestDeduplicator(config.RequestDeduplicatorDB)
+	if err != nil {
+		config.Logger.Error("failed to initialise persistent request deduplicator, falling back to in-memory", zap.Error(err))
+		return NewInMemoryRequestDeduplicator()
+	}
+	return deduplicator
 }
 
 func (p *Server) generateSharedKey(publicKey *ecdsa.PublicKey) ([]byte, error) {
@@ -119,12 +218,16 @@ func (p *Server) ValidateRegistration(publicKey *ecdsa.PublicKey, payload []byte
 	return registration, nil
 }
 
-func (p *Server) HandlePushNotificationQuery(query *protobuf.PushNotificationQuery) *protobuf.PushNotificationQueryResponse {
+func (p *Server) HandlePushNotificationQuery(publicKey *ecdsa.PublicKey, query *protobuf.PushNotificationQuery) *protobuf.PushNotificationQueryResponse {
 	response := &protobuf.PushNotificationQueryResponse{}
 	if query == nil || len(query.PublicKeys) == 0 {
 		return response
 	}
 
+	if !allow(p.queryLimiter, string(common.HashPublicKey(publicKey))) {
+		return response
+	}
+
 	registrations, err := p.persistence.GetPushNotificationRegistrationByPublicKeys(query.PublicKeys)
 	if err != nil {
 		// TODO: log errors
@@ -151,7 +254,7 @@ func (p *Server) HandlePushNotificationQuery(query *protobuf.PushNotificationQue
 	return response
 }
 
-func (p *Server) HandlePushNotificationRequest(request *protobuf.PushNotificationRequest) *protobuf.PushNotificationResponse {
+func (p *Server) HandlePushNotificationRequest(publicKey *ecdsa.PublicKey, request *protobuf.PushNotificationRequest) *protobuf.PushNotificationResponse {
 	response := &protobuf.PushNotificationResponse{}
 	// We don't even send a response in this case
 	if request == nil || len(request.MessageId) == 0 {
@@ -160,16 +263,43 @@ func (p *Server) HandlePushNotificationRequest(request *protobuf.PushNotificatio
 
 	response.MessageId = request.MessageId
 
+	// Both limiters below are keyed on the requester (sender), never on the
+	// InstallationId of a recipient registration -- keying on the recipient
+	// would let any sender drain a victim's bucket and deny them service.
+	if !allow(p.requestLimiter, string(common.HashPublicKey(publicKey))) || !allow(p.installationLimiter, request.InstallationId) {
+		for _, pn := range request.Requests {
+			response.Reports = append(response.Reports, &protobuf.PushNotificationReport{
+				PublicKey:      pn.PublicKey,
+				InstallationId: pn.InstallationId,
+				Error:          protobuf.PushNotificationReport_RATE_LIMITED,
+			})
+		}
+		return response
+	}
+
 	// Collect successful requests & registrations
 	var requestAndRegistrations []*RequestAndRegistration
 
 	for _, pn := range request.Requests {
-		registration, err := p.persistence.GetPushNotificationRegistrationByPublicKeyAndInstallationID(pn.PublicKey, pn.InstallationId)
 		report := &protobuf.PushNotificationReport{
 			PublicKey:      pn.PublicKey,
 			InstallationId: pn.InstallationId,
 		}
 
+		// Protocol messages can be redelivered; if we've already handled a
+		// push notification request for this message on this installation,
+		// report success without re-hitting the upstream notifier.
+		alreadyProcessed, err := p.requestDeduplicator.WasProcessed(request.MessageId, pn.InstallationId)
+		if err != nil {
+			// TODO: log error
+		} else if alreadyProcessed {
+			report.Success = true
+			response.Reports = append(response.Reports, report)
+			continue
+		}
+
+		registration, err := p.persistence.GetPushNotificationRegistrationByPublicKeyAndInstallationID(pn.PublicKey, pn.InstallationId)
+
 		if err != nil {
 			// TODO: log error
 			report.Error = protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE
@@ -177,6 +307,10 @@ func (p *Server) HandlePushNotificationRequest(request *protobuf.PushNotificatio
 			report.Error = protobuf.PushNotificationReport_NOT_REGISTERED
 		} else if registration.AccessToken != pn.AccessToken {
 			report.Error = protobuf.PushNotificationReport_WRONG_TOKEN
+		} else if len(registration.AllowedUserList) > 0 && !containsPublicKey(registration.AllowedUserList, publicKey) {
+			report.Error = protobuf.PushNotificationReport_NOT_AUTHORIZED
+		} else if len(pn.ChatId) > 0 && containsChatID(registration.BlockedChatList, pn.ChatId) {
+			report.Error = protobuf.PushNotificationReport_CHAT_BLOCKED
 		} else {
 			// For now we just assume that the notification will be successful
 			requestAndRegistrations = append(requestAndRegistrations, &RequestAndRegistration{
@@ -193,16 +327,68 @@ func (p *Server) HandlePushNotificationRequest(request *protobuf.PushNotificatio
 		return response
 	}
 
-	// This can be done asynchronously
-	goRushRequest := PushNotificationRegistrationToGoRushRequest(requestAndRegistrations)
-	err := sendGoRushNotification(goRushRequest, p.config.GorushURL)
-	if err != nil {
-		// TODO: handle this error?
+	// Fan out each registration to the backend that matches its token type,
+	// overwriting the optimistic "Success=true" reports collected above with
+	// the real outcome once delivery has been attempted.
+	reportByKey := make(map[string]*protobuf.PushNotificationReport)
+	for _, report := range response.Reports {
+		reportByKey[pushNotificationReportKey(report.PublicKey, report.InstallationId)] = report
+	}
+
+	for _, requestAndRegistration := range requestAndRegistrations {
+		notifier := p.notifierForTokenType(requestAndRegistration.Registration.TokenType)
+		success, errorType, shouldUnregister := notifier.Notify(context.Background(), requestAndRegistration)
+
+		report := reportByKey[pushNotificationReportKey(requestAndRegistration.Request.PublicKey, requestAndRegistration.Request.InstallationId)]
+		report.Success = success
+		report.Error = errorType
+
+		if success {
+			if err := p.requestDeduplicator.MarkProcessed(request.MessageId, requestAndRegistration.Request.InstallationId); err != nil {
+				p.config.Logger.Error("failed to record processed push notification request", zap.Error(err))
+			}
+		}
+
+		if shouldUnregister {
+			if err := p.persistence.DeletePushNotificationRegistration(requestAndRegistration.Request.PublicKey, requestAndRegistration.Request.InstallationId); err != nil {
+				p.config.Logger.Error("failed to deregister stale token", zap.Error(err))
+			}
+		}
 	}
 
 	return response
 }
 
+func pushNotificationReportKey(publicKey []byte, installationID string) string {
+	return string(publicKey) + "-" + installationID
+}
+
+// containsPublicKey reports whether an allowed-user-list contains a given
+// public key. Entries are stored hashed (see common.HashPublicKey), the same
+// way registrations are keyed by public key, so the raw key is hashed before
+// comparing rather than compared byte-for-byte.
+func containsPublicKey(allowedUserList [][]byte, publicKey *ecdsa.PublicKey) bool {
+	hashed := common.HashPublicKey(publicKey)
+	for _, allowed := range allowedUserList {
+		if bytes.Equal(allowed, hashed) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsChatID reports whether a blocked-chat-list contains a given chat
+// id. Both sides are hashed (see pn.ChatId on PushNotificationRequestInfo)
+// so the push-notification-server never has to handle a chat's plaintext id.
+func containsChatID(blockedChatList [][]byte, chatID []byte) bool {
+	for _, blocked := range blockedChatList {
+		if bytes.Equal(blocked, chatID) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) HandlePushNotificationRegistration(publicKey *ecdsa.PublicKey, payload []byte) *protobuf.PushNotificationRegistrationResponse {
 
 	s.config.Logger.Debug("handling push notification registration")
@@ -210,6 +396,11 @@ func (s *Server) HandlePushNotificationRegistration(publicKey *ecdsa.PublicKey,
 		RequestId: common.Shake256(payload),
 	}
 
+	if !allow(s.registrationLimiter, string(common.HashPublicKey(publicKey))) {
+		response.Error = protobuf.PushNotificationRegistrationResponse_RATE_LIMITED
+		return response
+	}
+
 	registration, err := s.ValidateRegistration(publicKey, payload)
 
 	if err != nil {
@@ -222,6 +413,11 @@ func (s *Server) HandlePushNotificationRegistration(publicKey *ecdsa.PublicKey,
 		return response
 	}
 
+	if !allow(s.installationLimiter, registration.InstallationId) {
+		response.Error = protobuf.PushNotificationRegistrationResponse_RATE_LIMITED
+		return response
+	}
+
 	if registration.Unregister {
 		// We save an empty registration, only keeping version and installation-id
 		emptyRegistration := &protobuf.PushNotificationRegistration{
@@ -267,7 +463,7 @@ func (p *Server) HandlePushNotificationRegistration2(publicKey *ecdsa.PublicKey,
 }
 
 func (p *Server) HandlePushNotificationQuery2(publicKey *ecdsa.PublicKey, query protobuf.PushNotificationQuery) error {
-	response := p.HandlePushNotificationQuery(&query)
+	response := p.HandlePushNotificationQuery(publicKey, &query)
 	if response == nil {
 		return nil
 	}
@@ -288,7 +484,7 @@ func (p *Server) HandlePushNotificationQuery2(publicKey *ecdsa.PublicKey, query
 
 func (p *Server) HandlePushNotificationRequest2(publicKey *ecdsa.PublicKey,
 	request protobuf.PushNotificationRequest) error {
-	response := p.HandlePushNotificationRequest(&request)
+	response := p.HandlePushNotificationRequest(publicKey, &request)
 	if response == nil {
 		return nil
 	}
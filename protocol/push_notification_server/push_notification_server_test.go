@@ -0,0 +1,174 @@
+package push_notification_server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/status-im/status-go/eth-node/crypto"
+	"github.com/status-im/status-go/protocol/common"
+	"github.com/status-im/status-go/protocol/protobuf"
+)
+
+type fakePersistence struct {
+	registrations map[string]*protobuf.PushNotificationRegistration
+}
+
+func newFakePersistence() *fakePersistence {
+	return &fakePersistence{registrations: make(map[string]*protobuf.PushNotificationRegistration)}
+}
+
+func registrationKey(publicKey []byte, installationID string) string {
+	return string(publicKey) + "-" + installationID
+}
+
+func (f *fakePersistence) GetPushNotificationRegistrationByPublicKeyAndInstallationID(publicKey []byte, installationID string) (*protobuf.PushNotificationRegistration, error) {
+	return f.registrations[registrationKey(publicKey, installationID)], nil
+}
+
+func (f *fakePersistence) GetPushNotificationRegistrationByPublicKeys(publicKeys [][]byte) ([]*PushNotificationIDAndRegistration, error) {
+	return nil, nil
+}
+
+func (f *fakePersistence) SavePushNotificationRegistration(publicKey []byte, registration *protobuf.PushNotificationRegistration) error {
+	f.registrations[registrationKey(publicKey, registration.InstallationId)] = registration
+	return nil
+}
+
+func (f *fakePersistence) DeletePushNotificationRegistration(publicKey []byte, installationID string) error {
+	delete(f.registrations, registrationKey(publicKey, installationID))
+	return nil
+}
+
+// countingNotifier lets tests assert that the upstream notifier isn't hit
+// again for a request that has already been processed.
+type countingNotifier struct {
+	calls int
+}
+
+func (c *countingNotifier) Notify(ctx context.Context, requestAndRegistration *RequestAndRegistration) (bool, protobuf.PushNotificationReport_ErrorType, bool) {
+	c.calls++
+	return true, protobuf.PushNotificationReport_UNKNOWN_ERROR_TYPE, false
+}
+
+func newTestServer(t *testing.T, persistence Persistence) (*Server, *countingNotifier) {
+	identity, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	server := New(&Config{Identity: identity, Logger: zap.NewNop(), GorushURL: "http://localhost"}, persistence, nil)
+	notifier := &countingNotifier{}
+	server.gorushNotifier = notifier
+	return server, notifier
+}
+
+func TestHandlePushNotificationRequestNotAuthorized(t *testing.T) {
+	persistence := newFakePersistence()
+	server, notifier := newTestServer(t, persistence)
+
+	sender, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	allowedSender, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	installationID := "installation-1"
+	targetPublicKey := []byte("target-key")
+	persistence.registrations[registrationKey(targetPublicKey, installationID)] = &protobuf.PushNotificationRegistration{
+		InstallationId:  installationID,
+		AccessToken:     "a-token",
+		Token:           "device-token",
+		AllowedUserList: [][]byte{common.HashPublicKey(&allowedSender.PublicKey)},
+	}
+
+	request := &protobuf.PushNotificationRequest{
+		MessageId: "message-1",
+		Requests: []*protobuf.PushNotificationRequestInfo{
+			{
+				PublicKey:      targetPublicKey,
+				InstallationId: installationID,
+				AccessToken:    "a-token",
+			},
+		},
+	}
+
+	response := server.HandlePushNotificationRequest(&sender.PublicKey, request)
+	require.Len(t, response.Reports, 1)
+	require.False(t, response.Reports[0].Success)
+	require.Equal(t, protobuf.PushNotificationReport_NOT_AUTHORIZED, response.Reports[0].Error)
+	require.Equal(t, 0, notifier.calls)
+}
+
+func TestHandlePushNotificationRequestChatBlocked(t *testing.T) {
+	persistence := newFakePersistence()
+	server, notifier := newTestServer(t, persistence)
+
+	sender, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	installationID := "installation-1"
+	targetPublicKey := []byte("target-key")
+	blockedChatID := []byte("blocked-chat-hash")
+	persistence.registrations[registrationKey(targetPublicKey, installationID)] = &protobuf.PushNotificationRegistration{
+		InstallationId:  installationID,
+		AccessToken:     "a-token",
+		Token:           "device-token",
+		BlockedChatList: [][]byte{blockedChatID},
+	}
+
+	request := &protobuf.PushNotificationRequest{
+		MessageId: "message-1",
+		Requests: []*protobuf.PushNotificationRequestInfo{
+			{
+				PublicKey:      targetPublicKey,
+				InstallationId: installationID,
+				AccessToken:    "a-token",
+				ChatId:         blockedChatID,
+			},
+		},
+	}
+
+	response := server.HandlePushNotificationRequest(&sender.PublicKey, request)
+	require.Len(t, response.Reports, 1)
+	require.False(t, response.Reports[0].Success)
+	require.Equal(t, protobuf.PushNotificationReport_CHAT_BLOCKED, response.Reports[0].Error)
+	require.Equal(t, 0, notifier.calls)
+}
+
+func TestHandlePushNotificationRequestDeduplicatesReplayedMessages(t *testing.T) {
+	persistence := newFakePersistence()
+	server, notifier := newTestServer(t, persistence)
+
+	sender, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	installationID := "installation-1"
+	targetPublicKey := []byte("target-key")
+	persistence.registrations[registrationKey(targetPublicKey, installationID)] = &protobuf.PushNotificationRegistration{
+		InstallationId: installationID,
+		AccessToken:    "a-token",
+		Token:          "device-token",
+	}
+
+	request := &protobuf.PushNotificationRequest{
+		MessageId: "message-1",
+		Requests: []*protobuf.PushNotificationRequestInfo{
+			{
+				PublicKey:      targetPublicKey,
+				InstallationId: installationID,
+				AccessToken:    "a-token",
+			},
+		},
+	}
+
+	first := server.HandlePushNotificationRequest(&sender.PublicKey, request)
+	require.Len(t, first.Reports, 1)
+	require.True(t, first.Reports[0].Success)
+	require.Equal(t, 1, notifier.calls)
+
+	// Redelivery of the same protocol message should not hit the notifier again.
+	second := server.HandlePushNotificationRequest(&sender.PublicKey, request)
+	require.Len(t, second.Reports, 1)
+	require.True(t, second.Reports[0].Success)
+	require.Equal(t, 1, notifier.calls)
+}
@@ -0,0 +1,111 @@
+package push_notification_server
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter decides whether an operation identified by key is allowed to
+// proceed right now. It is an interface rather than a concrete type so that
+// operators running more than one push-notification-server instance behind
+// a load balancer can plug in a shared backend (Redis, memcached, ...)
+// instead of the in-memory default, which only rate-limits per-process.
+// New always builds an InMemoryRateLimiter for each configured
+// RateLimitConfig; Config has no field for supplying another implementation.
+type RateLimiter interface {
+	// Allow reports whether an operation identified by key may proceed,
+	// consuming one token from its bucket if so.
+	Allow(key string) bool
+}
+
+// RateLimitConfig configures a token-bucket rate limiter.
+type RateLimitConfig struct {
+	// PerSecond is the sustained number of operations allowed per second for
+	// a given key.
+	PerSecond float64
+	// Burst is the maximum number of operations that can be performed in a
+	// single burst, regardless of PerSecond.
+	Burst int
+}
+
+// rateLimiterEntryTTL is how long an idle key's bucket is kept around. Keys
+// are attacker-controlled (hashed public keys, installation ids), so without
+// eviction the map would grow without bound and become its own
+// memory-exhaustion vector.
+const rateLimiterEntryTTL = 10 * time.Minute
+
+// InMemoryRateLimiter is a process-local token-bucket RateLimiter, keeping
+// one golang.org/x/time/rate.Limiter per key. New uses it for every operation
+// class that has a RateLimitConfig; it suits a single-instance deployment.
+type InMemoryRateLimiter struct {
+	config *RateLimitConfig
+
+	mu       sync.Mutex
+	limiters map[string]*rateLimiterEntry
+
+	stop chan struct{}
+}
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func NewInMemoryRateLimiter(config *RateLimitConfig) *InMemoryRateLimiter {
+	r := &InMemoryRateLimiter{
+		config:   config,
+		limiters: make(map[string]*rateLimiterEntry),
+		stop:     make(chan struct{}),
+	}
+	go r.evictLoop()
+	return r
+}
+
+func (r *InMemoryRateLimiter) Allow(key string) bool {
+	r.mu.Lock()
+	entry, ok := r.limiters[key]
+	if !ok {
+		entry = &rateLimiterEntry{limiter: rate.NewLimiter(rate.Limit(r.config.PerSecond), r.config.Burst)}
+		r.limiters[key] = entry
+	}
+	entry.lastUsed = time.Now()
+	allowed := entry.limiter.Allow()
+	r.mu.Unlock()
+
+	return allowed
+}
+
+// Close stops the background eviction goroutine. It is safe to skip calling
+// this for a server-lifetime limiter; it exists mainly so tests can shut one
+// down cleanly.
+func (r *InMemoryRateLimiter) Close() {
+	close(r.stop)
+}
+
+func (r *InMemoryRateLimiter) evictLoop() {
+	ticker := time.NewTicker(rateLimiterEntryTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.evictExpired()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *InMemoryRateLimiter) evictExpired() {
+	cutoff := time.Now().Add(-rateLimiterEntryTTL)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, entry := range r.limiters {
+		if entry.lastUsed.Before(cutoff) {
+			delete(r.limiters, key)
+		}
+	}
+}
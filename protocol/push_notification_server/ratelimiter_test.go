@@ -0,0 +1,39 @@
+package push_notification_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryRateLimiterAllowsBurstThenLimits(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(&RateLimitConfig{PerSecond: 1, Burst: 2})
+	defer limiter.Close()
+
+	require.True(t, limiter.Allow("key"))
+	require.True(t, limiter.Allow("key"))
+	require.False(t, limiter.Allow("key"))
+}
+
+func TestInMemoryRateLimiterKeysAreIndependent(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(&RateLimitConfig{PerSecond: 1, Burst: 1})
+	defer limiter.Close()
+
+	require.True(t, limiter.Allow("a"))
+	require.True(t, limiter.Allow("b"))
+	require.False(t, limiter.Allow("a"))
+}
+
+func TestInMemoryRateLimiterEvictsExpiredEntries(t *testing.T) {
+	limiter := NewInMemoryRateLimiter(&RateLimitConfig{PerSecond: 1, Burst: 1})
+	defer limiter.Close()
+
+	require.True(t, limiter.Allow("key"))
+	require.Len(t, limiter.limiters, 1)
+
+	limiter.limiters["key"].lastUsed = time.Now().Add(-2 * rateLimiterEntryTTL)
+	limiter.evictExpired()
+
+	require.Len(t, limiter.limiters, 0)
+}